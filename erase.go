@@ -1,13 +1,16 @@
 package main
 
 import (
+	"bytes"
+	crand "crypto/rand"
 	"fmt"
 	"io/fs"
-	"math/rand"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
 )
 
 const maxFileDescriptors = 50
@@ -19,6 +22,24 @@ const (
 	StatusTypeDiscovered
 	StatusTypeDone
 	StatusTypeFinal
+	// StatusTypeWarning reports a non-fatal condition, e.g. a target
+	// living on a copy-on-write filesystem.
+	StatusTypeWarning
+	// StatusTypeSynced reports how long an fsync after a pass took.
+	// AdditionalData is a time.Duration.
+	StatusTypeSynced
+	// StatusTypeProgress reports overall run progress after a write
+	// batch. BytesWritten/TotalBytes are run-wide aggregates across all
+	// files and passes; PassIndex/PassCount locate the batch within the
+	// current file's scheme. AdditionalData is the current throughput
+	// in bytes/second.
+	StatusTypeProgress
+	// StatusTypeRenamed reports a name-scrubbing rename; Path is the new,
+	// randomly generated name.
+	StatusTypeRenamed
+	// StatusTypeRemoved reports that a scrubbed file or directory was
+	// removed; Path is its original name.
+	StatusTypeRemoved
 )
 
 type status struct {
@@ -26,6 +47,11 @@ type status struct {
 	Type    statusType
 	Path    string
 
+	BytesWritten int64
+	TotalBytes   int64
+	PassIndex    int
+	PassCount    int
+
 	AdditionalData interface{}
 }
 
@@ -40,6 +66,22 @@ func (s status) String() string {
 		}
 		str += fmt.Sprintf("%s: %s", s.AdditionalData, s.Path)
 
+	// In this case AdditionalData is of type error, holding the warning text.
+	case StatusTypeWarning:
+		str += fmt.Sprintf("%s: %s", s.AdditionalData, s.Path)
+
+	// In this case AdditionalData is of type time.Duration.
+	case StatusTypeSynced:
+		str += fmt.Sprintf("'%s' (%s)", s.Path, s.AdditionalData)
+
+	case StatusTypeProgress:
+		rate, _ := s.AdditionalData.(float64)
+		str += fmt.Sprintf("'%s' pass %d/%d: %s / %s @ %s/s", s.Path, s.PassIndex, s.PassCount,
+			humanize.IBytes(uint64(s.BytesWritten)), humanize.IBytes(uint64(s.TotalBytes)), humanize.IBytes(uint64(rate)))
+
+	case StatusTypeFinal:
+		str += fmt.Sprintf("'%s' (took %s)", s.Path, s.AdditionalData)
+
 	default:
 		str += fmt.Sprintf("'%s'", s.Path)
 	}
@@ -47,36 +89,54 @@ func (s status) String() string {
 	return str
 }
 
-func erase(rootPath string) <-chan status {
-	statusChan := make(chan status)
-
-	var absolutePathStatus *status
-	absolutePath, err := filepath.Abs(rootPath)
-	if err != nil {
-		absolutePathStatus = &status{"Error while converting path to absolute", StatusTypeErr, "", err}
-		return statusChan
+// jsonName is the stable, lowercase name used for this statusType in
+// NDJSON output; see output.go.
+func (t statusType) jsonName() string {
+	switch t {
+	case StatusTypeErr:
+		return "error"
+	case StatusTypeDiscovered:
+		return "discovered"
+	case StatusTypeDone:
+		return "done"
+	case StatusTypeFinal:
+		return "final"
+	case StatusTypeWarning:
+		return "warning"
+	case StatusTypeSynced:
+		return "synced"
+	case StatusTypeProgress:
+		return "progress"
+	case StatusTypeRenamed:
+		return "renamed"
+	case StatusTypeRemoved:
+		return "removed"
+	default:
+		return "unknown"
 	}
+}
 
-	friendlyStatusChan := make(chan status)
-	go func() {
-		unnecessaryPathPrefix := filepath.Dir(absolutePath) + string(filepath.Separator)
-
-		for status := range statusChan {
-			if status.Type != StatusTypeFinal {
-				status.Path = strings.Replace(status.Path, unnecessaryPathPrefix, "", 1)
-			}
-			friendlyStatusChan <- status
-		}
-		close(friendlyStatusChan)
-	}()
+// Erase walks each of rootPaths and overwrites every regular file found
+// under it with scheme, using a single worker pool (capped at
+// maxFileDescriptors) shared across all roots. include/exclude, when
+// non-empty, restrict which files (relative to whichever root they were
+// found under) are erased; exclude always wins. A `*` in either never
+// crosses a path separator (use `**/` to match into subdirectories);
+// any include pattern that never matches a file across the whole run is
+// reported as a warning, since that's usually a sign the pattern didn't
+// mean what the caller thought. Unless keepNames is set, every erased
+// file and any directory left empty by the run is also renamed through
+// a sequence of random names and removed.
+func Erase(rootPaths []string, scheme Scheme, allowCow bool, include, exclude []globPattern, keepNames bool) <-chan status {
+	statusChan := make(chan status)
 
 	go func() {
-		if absolutePathStatus != nil {
-			statusChan <- *absolutePathStatus
-			return
-		}
+		tracker := newProgressTracker()
+		passCount := len(scheme.Passes())
+		includeMatched := make([]bool, len(include))
 
 		filesToErasePathChan := make(chan string)
+		var processedRoots []string
 
 		var eraseWorkersWg sync.WaitGroup
 		eraseWorkersWg.Add(maxFileDescriptors)
@@ -84,163 +144,300 @@ func erase(rootPath string) <-chan status {
 		for i := 0; i < maxFileDescriptors; i++ {
 			go func() {
 				for fileToErasePath := range filesToErasePathChan {
-					eraseFile(fileToErasePath, statusChan)
+					eraseFile(fileToErasePath, scheme, tracker, keepNames, statusChan)
 				}
 				eraseWorkersWg.Done()
 			}()
 		}
 
-		err = filepath.WalkDir(absolutePath, func(path string, d fs.DirEntry, err error) error {
+		for _, rootPath := range rootPaths {
+			absolutePath, err := filepath.Abs(rootPath)
 			if err != nil {
-				statusChan <- status{"Error while traversing", StatusTypeErr, path, err}
-				return nil
+				statusChan <- status{Message: "Error while converting path to absolute", Type: StatusTypeErr, Path: rootPath, AdditionalData: err}
+				continue
 			}
 
-			dType := d.Type()
-			if d.IsDir() || dType == fs.ModeSymlink || dType == fs.ModeDir {
-				return nil
+			if !allowCow {
+				if fsName, err := cowFilesystemName(absolutePath); err == nil && fsName != "" {
+					statusChan <- status{
+						Message: "CoW filesystem", Type: StatusTypeWarning, Path: absolutePath,
+						AdditionalData: fmt.Errorf("%s does not guarantee in-place overwrite; pass -allow-cow to erase anyway", fsName),
+					}
+					continue
+				}
 			}
 
-			statusChan <- status{"Discovered file", StatusTypeDiscovered, path, nil}
-			filesToErasePathChan <- path
+			err = filepath.WalkDir(absolutePath, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					statusChan <- status{Message: "Error while traversing", Type: StatusTypeErr, Path: path, AdditionalData: err}
+					return nil
+				}
 
-			return nil
-		})
+				dType := d.Type()
+				if d.IsDir() || dType == fs.ModeSymlink || dType == fs.ModeDir {
+					return nil
+				}
+
+				if rel, err := filepath.Rel(absolutePath, path); err == nil && !pathMatches(rel, include, exclude, includeMatched) {
+					return nil
+				}
+
+				if info, err := d.Info(); err == nil {
+					tracker.addTotal(info.Size() * int64(passCount))
+				}
+
+				statusChan <- status{Message: "Discovered file", Type: StatusTypeDiscovered, Path: path}
+				filesToErasePathChan <- path
+
+				return nil
+			})
+
+			if err != nil {
+				statusChan <- status{Message: "Error while traversing", Type: StatusTypeErr, Path: absolutePath, AdditionalData: err}
+			}
+
+			processedRoots = append(processedRoots, absolutePath)
+		}
 		close(filesToErasePathChan)
 
-		if err != nil {
-			statusChan <- status{"Error while traversing", StatusTypeErr, absolutePath, err}
-			return
+		for i, pattern := range include {
+			if !includeMatched[i] {
+				statusChan <- status{
+					Message: "Include pattern matched nothing", Type: StatusTypeWarning, Path: pattern.source,
+					AdditionalData: fmt.Errorf("remember a bare \"*\" doesn't cross a path separator; use \"**/%s\" to also match in subdirectories", pattern.source),
+				}
+			}
 		}
 
 		eraseWorkersWg.Wait()
-		statusChan <- status{"Erased requested path", StatusTypeFinal, absolutePath, nil}
+
+		if !keepNames {
+			for _, root := range processedRoots {
+				removeEmptyDirs(root, statusChan)
+			}
+		}
+
+		statusChan <- status{
+			Message: "Erased requested paths", Type: StatusTypeFinal,
+			AdditionalData: tracker.elapsed(),
+		}
 		close(statusChan)
 	}()
 
-	return friendlyStatusChan
+	return statusChan
 }
 
-func eraseFile(fileToErasePath string, statusChan chan<- status) {
+func eraseFile(fileToErasePath string, scheme Scheme, tracker *progressTracker, keepNames bool, statusChan chan<- status) {
 	file, err := os.OpenFile(fileToErasePath, os.O_WRONLY, 0)
-	defer func() {
-		err = file.Close()
-		if err != nil {
-			statusChan <- status{"Error while closing file", StatusTypeErr, fileToErasePath, err}
-		}
-	}()
-
 	if err != nil {
-		statusChan <- status{"Error while opening file", StatusTypeErr, fileToErasePath, err}
+		statusChan <- status{Message: "Error while opening file", Type: StatusTypeErr, Path: fileToErasePath, AdditionalData: err}
 		return
 	}
 
 	fileInfo, err := file.Stat()
 	if err != nil {
-		statusChan <- status{"Error while reding file stats", StatusTypeErr, fileToErasePath, err}
+		statusChan <- status{Message: "Error while reding file stats", Type: StatusTypeErr, Path: fileToErasePath, AdditionalData: err}
+		file.Close()
 		return
 	}
 
 	fileSize := fileInfo.Size()
 
-	reportWriteErr := func(err error) {
+	passes := scheme.Passes()
+	for i, pass := range passes {
+		var seed [32]byte
+		if _, err := crand.Read(seed[:]); err != nil {
+			statusChan <- status{Message: "Error while seeding pass", Type: StatusTypeErr, Path: fileToErasePath, AdditionalData: err}
+			continue
+		}
+
+		progress := passProgress{
+			statusChan: statusChan,
+			tracker:    tracker,
+			path:       fileToErasePath,
+			passIndex:  i + 1,
+			passCount:  len(passes),
+		}
+
+		err := writeBytes(file, fileSize, pass.NewFiller(seed), progress)
 		if err != nil {
-			statusChan <- status{"Error while writing to file", StatusTypeErr, fileToErasePath, err}
+			statusChan <- status{Message: "Error while writing to file", Type: StatusTypeErr, Path: fileToErasePath, AdditionalData: err}
+			continue
 		}
-	}
 
-	r := rand.New(cryptoSource{})
-	reportWriteErr(writeBytes(file, fileSize, randomBytesGenerator(r.Int63())))
-	reportWriteErr(writeBytes(file, fileSize, setBytesGenerator(0xFF)))
-	reportWriteErr(writeBytes(file, fileSize, randomBytesGenerator(r.Int63())))
-	reportWriteErr(writeBytes(file, fileSize, setBytesGenerator(0x00)))
-	reportWriteErr(writeBytes(file, fileSize, setBytesGenerator(0xFF)))
-	reportWriteErr(writeBytes(file, fileSize, randomBytesGenerator(r.Int63())))
-	reportWriteErr(writeBytes(file, fileSize, setBytesGenerator(0x00)))
+		syncStart := time.Now()
+		if err := file.Sync(); err != nil {
+			statusChan <- status{Message: "Error while syncing file", Type: StatusTypeErr, Path: fileToErasePath, AdditionalData: err}
+		} else {
+			statusChan <- status{Message: "Synced pass", Type: StatusTypeSynced, Path: fileToErasePath, AdditionalData: time.Since(syncStart)}
+		}
+
+		if pass.Verify {
+			err := verifyBytes(file, fileSize, pass.NewFiller(seed))
+			if err != nil {
+				statusChan <- status{Message: "Error while verifying pass", Type: StatusTypeErr, Path: fileToErasePath, AdditionalData: err}
+			}
+		}
+	}
 
 	err = file.Truncate(0)
 	if err != nil {
-		statusChan <- status{"Error while truncating file", StatusTypeErr, fileToErasePath, err}
+		statusChan <- status{Message: "Error while truncating file", Type: StatusTypeErr, Path: fileToErasePath, AdditionalData: err}
+	}
+
+	if err := file.Close(); err != nil {
+		statusChan <- status{Message: "Error while closing file", Type: StatusTypeErr, Path: fileToErasePath, AdditionalData: err}
 	}
 
-	statusChan <- status{"Erased file", StatusTypeDone, fileToErasePath, nil}
+	statusChan <- status{Message: "Erased file", Type: StatusTypeDone, Path: fileToErasePath}
+
+	if !keepNames {
+		if err := scrubAndRemove(fileToErasePath, false, statusChan); err != nil {
+			statusChan <- status{Message: "Error while removing file", Type: StatusTypeErr, Path: fileToErasePath, AdditionalData: err}
+		}
+	}
 }
 
-type byteGeneratorFunc func() (byte, error)
+// batchFillerFunc fills an entire write batch in one call, e.g. by
+// tiling a fixed pattern across it or bulk-reading from a CSPRNG,
+// instead of producing one byte at a time.
+type batchFillerFunc func(buf []byte) error
 
 // BATCH_SIZE in byes, 5MB
 const BATCH_SIZE = 5_000_000
 
-func writeBytes(fd *os.File, size int64, byteGenerator byteGeneratorFunc) error {
+// passProgress carries everything writeBytes needs to report run-wide
+// progress for one pass of one file.
+type passProgress struct {
+	statusChan chan<- status
+	tracker    *progressTracker
+	path       string
+	passIndex  int
+	passCount  int
+}
+
+func writeBytes(fd *os.File, size int64, filler batchFillerFunc, progress passProgress) error {
 	batchCount := size / BATCH_SIZE
 	lastBatchSize := size - BATCH_SIZE*batchCount
 	offset := int64(0)
 
-	for i := 0; i < int(batchCount); i++ {
-		batch := make([]byte, BATCH_SIZE)
-		// Fill batch.
-		for batchIndex := 0; batchIndex < BATCH_SIZE; batchIndex++ {
-			b, err := byteGenerator()
-			if err != nil {
-				return err
-			}
+	report := func(n int64) {
+		written, total := progress.tracker.addWritten(n)
+		progress.statusChan <- status{
+			Message:        "Erasing file",
+			Type:           StatusTypeProgress,
+			Path:           progress.path,
+			BytesWritten:   written,
+			TotalBytes:     total,
+			PassIndex:      progress.passIndex,
+			PassCount:      progress.passCount,
+			AdditionalData: progress.tracker.rate(written),
+		}
+	}
 
-			batch[batchIndex] = b
+	batch := make([]byte, BATCH_SIZE)
+	for i := 0; i < int(batchCount); i++ {
+		if err := filler(batch); err != nil {
+			return err
 		}
 
-		_, err := fd.WriteAt(batch, offset)
-		if err != nil {
+		if _, err := fd.WriteAt(batch, offset); err != nil {
 			return err
 		}
 
 		offset += BATCH_SIZE
+		report(BATCH_SIZE)
 	}
 
-	lastBatch := make([]byte, lastBatchSize)
-	for batchIndex := 0; batchIndex < int(lastBatchSize); batchIndex++ {
-		b, err := byteGenerator()
-		if err != nil {
+	if lastBatchSize > 0 {
+		lastBatch := make([]byte, lastBatchSize)
+		if err := filler(lastBatch); err != nil {
 			return err
 		}
 
-		lastBatch[batchIndex] = b
-	}
+		if _, err := fd.WriteAt(lastBatch, offset); err != nil {
+			return err
+		}
 
-	_, err := fd.WriteAt(lastBatch, offset)
-	if err != nil {
-		return err
+		report(lastBatchSize)
 	}
 
 	return nil
 }
 
-func randomBytesGenerator(seed int64) byteGeneratorFunc {
-	r := rand.NewSource(seed)
-	var bytes [8]byte
-	offset := 0
+// verifyBytes reads size bytes back from fd and compares them against
+// filler, failing on the first mismatching batch.
+func verifyBytes(fd *os.File, size int64, filler batchFillerFunc) error {
+	batchCount := size / BATCH_SIZE
+	lastBatchSize := size - BATCH_SIZE*batchCount
+	offset := int64(0)
+
+	verifyBatch := func(want []byte) error {
+		got := make([]byte, len(want))
+		if _, err := fd.ReadAt(got, offset); err != nil {
+			return err
+		}
 
-	regenerate := func() {
-		v := r.Int63()
-		bytes = [8]byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24), byte(v >> 32), byte(v >> 40), byte(v >> 48), byte(v >> 56)}
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("data at offset %d does not match what was written", offset)
+		}
+
+		return nil
 	}
 
-	regenerate()
+	batch := make([]byte, BATCH_SIZE)
+	for i := 0; i < int(batchCount); i++ {
+		if err := filler(batch); err != nil {
+			return err
+		}
 
-	return func() (byte, error) {
-		if offset == 8 {
-			regenerate()
-			offset = 0
+		if err := verifyBatch(batch); err != nil {
+			return err
 		}
 
-		b := bytes[offset]
-		offset++
+		offset += BATCH_SIZE
+	}
 
-		return b, nil
+	if lastBatchSize > 0 {
+		lastBatch := make([]byte, lastBatchSize)
+		if err := filler(lastBatch); err != nil {
+			return err
+		}
+
+		if err := verifyBatch(lastBatch); err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
 
-func setBytesGenerator(setByte byte) byteGeneratorFunc {
-	return func() (byte, error) {
-		return setByte, nil
+// tiledPatternFiller fills buf by repeating pattern across it in bulk
+// (via doubling copy(), not a byte-at-a-time loop), carrying the tiling
+// phase (offset) across calls so a pattern whose length doesn't evenly
+// divide BATCH_SIZE still repeats continuously across batch boundaries
+// instead of restarting at offset 0 every call.
+func tiledPatternFiller(pattern []byte) batchFillerFunc {
+	offset := 0
+	rotated := make([]byte, len(pattern))
+
+	return func(buf []byte) error {
+		if len(buf) == 0 {
+			return nil
+		}
+
+		// rotated is pattern read starting at offset and wrapping around,
+		// i.e. the same infinite tiling as pattern, just realigned so
+		// tiling rotated from position 0 continues it.
+		copy(rotated, pattern[offset:])
+		copy(rotated[len(pattern)-offset:], pattern[:offset])
+
+		n := copy(buf, rotated)
+		for n < len(buf) {
+			n += copy(buf[n:], buf[:n])
+		}
+
+		offset = (offset + len(buf)) % len(pattern)
+		return nil
 	}
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/gobwas/glob"
+)
+
+// globPattern pairs a compiled glob with the source text it was
+// compiled from, so callers can report which patterns never matched
+// anything over the whole run.
+type globPattern struct {
+	source   string
+	compiled glob.Glob
+}
+
+// pathMatches reports whether rel (a path relative to the root being
+// walked) should be erased given the -include/-exclude glob lists.
+// exclude always wins; an empty include list means "everything". Every
+// include pattern that matches rel has its matched flag in
+// includeMatched set, by index into include, so the caller can warn
+// about include patterns that never matched anything.
+func pathMatches(rel string, include, exclude []globPattern, includeMatched []bool) bool {
+	for _, g := range exclude {
+		if g.compiled.Match(rel) {
+			return false
+		}
+	}
+
+	if len(include) == 0 {
+		return true
+	}
+
+	matched := false
+	for i, g := range include {
+		if g.compiled.Match(rel) {
+			includeMatched[i] = true
+			matched = true
+		}
+	}
+
+	return matched
+}
+
+// globListFlag implements flag.Value, letting -include/-exclude be given
+// multiple times to build up a list of glob patterns.
+type globListFlag []globPattern
+
+func (g *globListFlag) String() string {
+	return ""
+}
+
+func (g *globListFlag) Set(pattern string) error {
+	compiled, err := glob.Compile(pattern, filepath.Separator)
+	if err != nil {
+		return err
+	}
+
+	*g = append(*g, globPattern{source: pattern, compiled: compiled})
+	return nil
+}
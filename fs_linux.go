@@ -0,0 +1,35 @@
+//go:build linux
+
+package main
+
+import "syscall"
+
+// Filesystem magic numbers from linux/magic.h for filesystems where an
+// in-place overwrite is not guaranteed to reach the original blocks:
+// btrfs and ZFS are copy-on-write, f2fs is log-structured flash.
+const (
+	btrfsSuperMagic = 0x9123683e
+	zfsSuperMagic   = 0x2fc12fc1
+	f2fsSuperMagic  = 0xf2f52010
+)
+
+// cowFilesystemName returns the name of the copy-on-write or
+// log-structured filesystem backing path, or "" if path's filesystem is
+// none of the ones erase knows to warn about.
+func cowFilesystemName(path string) (string, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return "", err
+	}
+
+	switch int64(stat.Type) {
+	case btrfsSuperMagic:
+		return "btrfs", nil
+	case zfsSuperMagic:
+		return "zfs", nil
+	case f2fsSuperMagic:
+		return "f2fs", nil
+	default:
+		return "", nil
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !windows
+
+package main
+
+// cowFilesystemName is only implemented on Linux (via statfs) and
+// Windows (via GetVolumeInformation, see fs_windows.go); on other
+// platforms (e.g. macOS) erase cannot yet tell, so it never warns.
+func cowFilesystemName(path string) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,38 @@
+//go:build windows
+
+package main
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+// cowFilesystemName returns the name of the copy-on-write filesystem
+// backing path's volume, or "" if path's filesystem is none of the ones
+// erase knows to warn about. It asks the volume for its filesystem name
+// via GetVolumeInformation rather than inspecting path directly, since
+// that's the only thing Windows exposes for this.
+func cowFilesystemName(path string) (string, error) {
+	volume := filepath.VolumeName(filepath.Dir(path))
+	if volume == "" {
+		return "", nil
+	}
+
+	rootPtr, err := windows.UTF16PtrFromString(volume + `\`)
+	if err != nil {
+		return "", err
+	}
+
+	var fsName [windows.MAX_PATH + 1]uint16
+	if err := windows.GetVolumeInformation(rootPtr, nil, 0, nil, nil, nil, &fsName[0], uint32(len(fsName))); err != nil {
+		return "", err
+	}
+
+	switch windows.UTF16ToString(fsName[:]) {
+	case "ReFS":
+		return "refs", nil
+	default:
+		return "", nil
+	}
+}
@@ -2,23 +2,46 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 )
 
 func main() {
 	// start := time.Now()
 
-	if 1 >= len(os.Args) {
-		fmt.Println("Usage: erase [path]")
+	schemeName := flag.String("scheme", "dod7", "erasure scheme: zero, dod3, dod7, gutmann, random:N")
+	allowCow := flag.Bool("allow-cow", false, "proceed even if the target lives on a copy-on-write filesystem")
+	keepNames := flag.Bool("keep-names", false, "only wipe file contents; don't scrub filenames or remove emptied directories")
+	output := flag.String("output", "text", "output format: text or json (NDJSON, one status object per line)")
+	var include, exclude globListFlag
+	flag.Var(&include, "include", "glob pattern (relative to each root) a file must match to be erased; * doesn't cross a directory separator, use **/ to match in subdirectories; may be repeated")
+	flag.Var(&exclude, "exclude", "glob pattern (relative to each root) that skips a file even if included; * doesn't cross a directory separator, use **/ to match in subdirectories; may be repeated")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		fmt.Println("Usage: erase [flags] path [path...]")
+		flag.PrintDefaults()
+		return
+	}
+	rootPaths := flag.Args()
+
+	scheme, err := schemeByName(*schemeName)
+	if err != nil {
+		fmt.Println(err)
 		return
 	}
 
-	rootPath := os.Args[1]
+	if *output != "text" && *output != "json" {
+		fmt.Printf("unknown -output %q: must be \"text\" or \"json\"\n", *output)
+		return
+	}
 
 	scanner := bufio.NewScanner(os.Stdin)
 
-	fmt.Print("You are about to permanently erase all files from: '", rootPath, "'\nAre you sure? This is IRREVERSIBLE (yes/no): ")
+	fmt.Print("You are about to permanently erase all files from: '", strings.Join(rootPaths, "', '"), "'\nAre you sure? This is IRREVERSIBLE (yes/no): ")
 	ok := false
 	for !ok {
 		scanner.Scan()
@@ -35,8 +58,36 @@ func main() {
 		ok = true
 	}
 
-	statusChan := Erase(rootPath)
+	statusChan := Erase(rootPaths, scheme, *allowCow, include, exclude, *keepNames)
 	for status := range statusChan {
+		if *output == "json" {
+			if err := printJSON(os.Stdout, status, scheme.Name()); err != nil {
+				fmt.Println(err)
+			}
+			continue
+		}
 		fmt.Println(status)
 	}
 }
+
+// schemeByName resolves the -scheme flag value to a Scheme.
+func schemeByName(name string) (Scheme, error) {
+	switch {
+	case name == "zero":
+		return SchemeZero, nil
+	case name == "dod3":
+		return SchemeDoD3, nil
+	case name == "dod7":
+		return SchemeDoD7, nil
+	case name == "gutmann":
+		return SchemeGutmann, nil
+	case strings.HasPrefix(name, "random:"):
+		n, err := strconv.Atoi(strings.TrimPrefix(name, "random:"))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid scheme %q: pass count must be a positive integer", name)
+		}
+		return SchemeRandom(n), nil
+	default:
+		return nil, fmt.Errorf("unknown scheme %q", name)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// jsonStatus is the NDJSON-serializable projection of a status, emitted
+// one per line by printJSON. Pass combines PassIndex/PassCount as
+// "i/n" so a consumer doesn't need two fields to locate a progress
+// update within a file's scheme.
+type jsonStatus struct {
+	Ts           string `json:"ts"`
+	Type         string `json:"type"`
+	Path         string `json:"path,omitempty"`
+	BytesWritten int64  `json:"bytes_written,omitempty"`
+	TotalBytes   int64  `json:"total_bytes,omitempty"`
+	Pass         string `json:"pass,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// printJSON writes s to w as a single NDJSON line. schemeName is
+// included on every line since status itself doesn't carry the scheme.
+func printJSON(w io.Writer, s status, schemeName string) error {
+	js := jsonStatus{
+		Ts:           time.Now().UTC().Format(time.RFC3339Nano),
+		Type:         s.Type.jsonName(),
+		Path:         s.Path,
+		BytesWritten: s.BytesWritten,
+		TotalBytes:   s.TotalBytes,
+		Scheme:       schemeName,
+	}
+
+	if s.PassCount > 0 {
+		js.Pass = fmt.Sprintf("%d/%d", s.PassIndex, s.PassCount)
+	}
+
+	if s.Type == StatusTypeErr || s.Type == StatusTypeWarning {
+		if err, ok := s.AdditionalData.(error); ok {
+			js.Error = err.Error()
+		}
+	}
+
+	return json.NewEncoder(w).Encode(js)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// progressTracker aggregates discovered and written byte counts across
+// every file and pass of a single Erase run so a consumer can render one
+// overall progress bar instead of per-file ones.
+type progressTracker struct {
+	startedAt time.Time
+
+	totalBytes   int64
+	writtenBytes int64
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{startedAt: time.Now()}
+}
+
+// addTotal records n more bytes that will eventually need to be written,
+// e.g. a newly discovered file's size times its scheme's pass count.
+func (p *progressTracker) addTotal(n int64) {
+	atomic.AddInt64(&p.totalBytes, n)
+}
+
+// addWritten records n more written bytes and returns the run-wide
+// written and total byte counts.
+func (p *progressTracker) addWritten(n int64) (written, total int64) {
+	written = atomic.AddInt64(&p.writtenBytes, n)
+	total = atomic.LoadInt64(&p.totalBytes)
+	return
+}
+
+// rate returns the average throughput in bytes/second since the run
+// started, given the written byte count at the time of the call.
+func (p *progressTracker) rate(written int64) float64 {
+	elapsed := time.Since(p.startedAt).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(written) / elapsed
+}
+
+func (p *progressTracker) elapsed() time.Duration {
+	return time.Since(p.startedAt)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+// randomRekeyInterval bounds how much keystream a single ChaCha20 key is
+// used for before cryptoRandomFiller derives the next one.
+const randomRekeyInterval = 64_000_000 // 64MB
+
+// cryptoRandomFiller returns a batchFillerFunc that fills batches from a
+// ChaCha20 keystream, re-keying every randomRekeyInterval bytes. Each
+// key is derived from the full 32 bytes of seed and a generation
+// counter via SHA-256, rather than drawn fresh from crypto/rand on
+// every rekey: seed itself already comes from a crypto/rand-backed draw
+// (see eraseFile) with its full entropy carried forward, and deriving
+// deterministically lets a Scheme's Verify pass regenerate the exact
+// same stream to check what was written.
+func cryptoRandomFiller(seed [32]byte) batchFillerFunc {
+	var generation uint64
+	var cipher *chacha20.Cipher
+	var sinceRekey int
+
+	rekey := func() error {
+		key := deriveKey(seed, generation)
+		generation++
+
+		c, err := chacha20.NewUnauthenticatedCipher(key[:], make([]byte, chacha20.NonceSize))
+		if err != nil {
+			return err
+		}
+
+		cipher = c
+		sinceRekey = 0
+		return nil
+	}
+
+	return func(buf []byte) error {
+		for off := 0; off < len(buf); {
+			if cipher == nil || sinceRekey >= randomRekeyInterval {
+				if err := rekey(); err != nil {
+					return err
+				}
+			}
+
+			n := len(buf) - off
+			if remaining := randomRekeyInterval - sinceRekey; n > remaining {
+				n = remaining
+			}
+
+			chunk := buf[off : off+n]
+			for i := range chunk {
+				chunk[i] = 0
+			}
+			cipher.XORKeyStream(chunk, chunk)
+
+			sinceRekey += n
+			off += n
+		}
+
+		return nil
+	}
+}
+
+func deriveKey(seed [32]byte, generation uint64) [32]byte {
+	var material [40]byte
+	copy(material[0:32], seed[:])
+	binary.LittleEndian.PutUint64(material[32:40], generation)
+
+	return sha256.Sum256(material[:])
+}
@@ -0,0 +1,144 @@
+package main
+
+import "fmt"
+
+// PassSpec describes a single overwrite pass. NewFiller builds the
+// batchFillerFunc for the pass given a per-file seed drawn fresh from
+// crypto/rand; fixed-byte and pattern passes ignore the seed. Verify
+// requests that the pass be read back and compared against the same
+// filler before moving on.
+type PassSpec struct {
+	Name      string
+	NewFiller func(seed [32]byte) batchFillerFunc
+	Verify    bool
+}
+
+// Scheme is an ordered sequence of overwrite passes applied to a file.
+type Scheme interface {
+	Name() string
+	Passes() []PassSpec
+}
+
+func randomPass() PassSpec {
+	return PassSpec{
+		Name:      "random",
+		NewFiller: cryptoRandomFiller,
+	}
+}
+
+func fixedPass(b byte) PassSpec {
+	return patternPass(fmt.Sprintf("0x%02X", b), []byte{b})
+}
+
+func patternPass(name string, pattern []byte) PassSpec {
+	return PassSpec{
+		Name:      name,
+		NewFiller: func(seed [32]byte) batchFillerFunc { return tiledPatternFiller(pattern) },
+	}
+}
+
+// gutmannPatterns are the 27 fixed byte patterns used by passes 5-31 of
+// the Gutmann method (Gutmann, "Secure Deletion of Data from Magnetic
+// and Solid-State Memory", 1996, Table 1). Each pattern is tiled to fill
+// a write batch rather than being regenerated byte by byte.
+var gutmannPatterns = [][]byte{
+	{0x55},
+	{0xAA},
+	{0x92, 0x49, 0x24},
+	{0x49, 0x24, 0x92},
+	{0x24, 0x92, 0x49},
+	{0x00},
+	{0x11},
+	{0x22},
+	{0x33},
+	{0x44},
+	{0x55},
+	{0x66},
+	{0x77},
+	{0x88},
+	{0x99},
+	{0xAA},
+	{0xBB},
+	{0xCC},
+	{0xDD},
+	{0xEE},
+	{0xFF},
+	{0x92, 0x49, 0x24},
+	{0x49, 0x24, 0x92},
+	{0x24, 0x92, 0x49},
+	{0x6D, 0xB6, 0xDB},
+	{0xB6, 0xDB, 0x6D},
+	{0xDB, 0x6D, 0xB6},
+}
+
+type schemeZero struct{}
+
+func (schemeZero) Name() string       { return "zero" }
+func (schemeZero) Passes() []PassSpec { return []PassSpec{fixedPass(0x00)} }
+
+type schemeDoD3 struct{}
+
+func (schemeDoD3) Name() string { return "dod3" }
+func (schemeDoD3) Passes() []PassSpec {
+	verifiedRandom := randomPass()
+	verifiedRandom.Verify = true
+	return []PassSpec{fixedPass(0x00), fixedPass(0xFF), verifiedRandom}
+}
+
+type schemeDoD7 struct{}
+
+func (schemeDoD7) Name() string { return "dod7" }
+func (schemeDoD7) Passes() []PassSpec {
+	return []PassSpec{
+		randomPass(),
+		fixedPass(0xFF),
+		randomPass(),
+		fixedPass(0x00),
+		fixedPass(0xFF),
+		randomPass(),
+		fixedPass(0x00),
+	}
+}
+
+type schemeGutmann struct{}
+
+func (schemeGutmann) Name() string { return "gutmann" }
+func (schemeGutmann) Passes() []PassSpec {
+	passes := make([]PassSpec, 0, 35)
+	for i := 0; i < 4; i++ {
+		passes = append(passes, randomPass())
+	}
+	for i, pattern := range gutmannPatterns {
+		passes = append(passes, patternPass(fmt.Sprintf("pattern-%d", i+5), pattern))
+	}
+	for i := 0; i < 4; i++ {
+		passes = append(passes, randomPass())
+	}
+	return passes
+}
+
+type schemeRandom struct{ passCount int }
+
+// SchemeRandom returns a scheme of n random-data passes.
+func SchemeRandom(n int) Scheme { return schemeRandom{passCount: n} }
+
+func (s schemeRandom) Name() string { return fmt.Sprintf("random:%d", s.passCount) }
+func (s schemeRandom) Passes() []PassSpec {
+	passes := make([]PassSpec, s.passCount)
+	for i := range passes {
+		passes[i] = randomPass()
+	}
+	return passes
+}
+
+// Built-in schemes.
+var (
+	// SchemeZero is a single 0x00 pass, matching NIST SP 800-88 Clear.
+	SchemeZero Scheme = schemeZero{}
+	// SchemeDoD3 is the 3-pass DoD 5220.22-M variant: 0x00, 0xFF, random with verify.
+	SchemeDoD3 Scheme = schemeDoD3{}
+	// SchemeDoD7 is the 7-pass DoD 5220.22-M variant and the tool's original behavior.
+	SchemeDoD7 Scheme = schemeDoD7{}
+	// SchemeGutmann is Peter Gutmann's 35-pass method.
+	SchemeGutmann Scheme = schemeGutmann{}
+)
@@ -0,0 +1,160 @@
+package main
+
+import (
+	crand "crypto/rand"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const randomNameAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// randomName returns an n-character random name built from
+// randomNameAlphabet.
+func randomName(n int) string {
+	b := make([]byte, n)
+	if _, err := crand.Read(b); err != nil {
+		// crypto/rand.Read on the OS CSPRNG practically never fails; if it
+		// does there is no sane fallback, so fall back to a fixed name
+		// rather than silently producing a weaker one.
+		for i := range b {
+			b[i] = byte(i)
+		}
+	}
+
+	for i, v := range b {
+		b[i] = randomNameAlphabet[int(v)%len(randomNameAlphabet)]
+	}
+
+	return string(b)
+}
+
+// syncDir fsyncs dir so a directory-entry rewrite (e.g. a rename) is
+// pushed to disk.
+func syncDir(dir string) error {
+	fd, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	return fd.Sync()
+}
+
+// reserveRandomFileName claims an n-character random filename in dir by
+// creating it exclusively (O_EXCL), retrying on collision. Up to
+// maxFileDescriptors worker goroutines may be scrubbing files in the
+// same directory concurrently, and a short name (as few as 1 character,
+// 36 possible values) collides often enough that an existence check
+// alone would still race; O_EXCL makes the claim atomic.
+//
+// This has no directory equivalent: os.Rename refuses to rename onto an
+// existing directory even if it's empty, so a directory can't be
+// reserved this way. uniqueRandomDirName below handles that case
+// instead.
+func reserveRandomFileName(dir string, n int) (string, error) {
+	for {
+		newPath := filepath.Join(dir, randomName(n))
+
+		f, err := os.OpenFile(newPath, os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			f.Close()
+			return newPath, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+	}
+}
+
+// uniqueRandomDirName picks an n-character random name in dir that
+// doesn't already name something there, retrying on collision. It only
+// checks rather than claims the name, which would race against a
+// concurrent renamer landing on the same name - safe here because the
+// only caller that scrubs directories (removeEmptyDirs) does so
+// single-threaded, after all file-scrubbing workers have finished, with
+// nothing else renaming into the same directory at the same time.
+func uniqueRandomDirName(dir string, n int) (string, error) {
+	for {
+		newPath := filepath.Join(dir, randomName(n))
+
+		if _, err := os.Lstat(newPath); os.IsNotExist(err) {
+			return newPath, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
+
+// scrubAndRemove renames path through increasingly short random names
+// (16, 8, 4, 1 characters), fsyncing the parent directory between
+// renames so each directory-entry rewrite is durable, then removes it.
+// isDir must say whether path is a directory, since files and
+// directories need different name-collision handling (see
+// reserveRandomFileName and uniqueRandomDirName). Renamed/Removed
+// statuses are reported against path's original name so callers can
+// still correlate them with what they discovered.
+func scrubAndRemove(path string, isDir bool, statusChan chan<- status) error {
+	dir := filepath.Dir(path)
+	current := path
+
+	for _, n := range []int{16, 8, 4, 1} {
+		var newPath string
+		var err error
+		if isDir {
+			newPath, err = uniqueRandomDirName(dir, n)
+		} else {
+			newPath, err = reserveRandomFileName(dir, n)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := os.Rename(current, newPath); err != nil {
+			return err
+		}
+		current = newPath
+
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+
+		statusChan <- status{Message: "Renamed", Type: StatusTypeRenamed, Path: newPath}
+	}
+
+	if err := os.Remove(current); err != nil {
+		return err
+	}
+
+	statusChan <- status{Message: "Removed", Type: StatusTypeRemoved, Path: path}
+	return nil
+}
+
+// removeEmptyDirs walks root a second time, bottom-up, scrubbing and
+// removing any directory left empty by the erase pass.
+func removeEmptyDirs(root string, statusChan chan<- status) {
+	var dirs []string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+
+	// Deepest directories first, so a parent is only considered once its
+	// children have already been removed.
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			continue
+		}
+
+		if err := scrubAndRemove(dir, true, statusChan); err != nil {
+			statusChan <- status{Message: "Error while removing directory", Type: StatusTypeErr, Path: dir, AdditionalData: err}
+		}
+	}
+}